@@ -0,0 +1,248 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package gobuild
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// builder 保存了 Build、BuildCross、BuildLibrary 共用的编译-监视-
+// 运行状态。
+type builder struct {
+	exts    []string
+	appName string
+	wd      string
+	appArgs []string
+	logs    chan *Log
+
+	verb          string            // go 子命令，build、test 或 vet
+	extraVerbArgs []string          // 紧跟在 verb 之后的固定参数，如 go test -c 的 "-c"
+	mainFiles     string            // go 命令最后的文件参数，可以为空
+	defaultToAll  bool              // mainFiles 为空时是否追加 "./..."
+	rawFlags      map[string]string // 模板展开前的原始 flags，每次 rebuild 都会重新展开
+
+	mode      Mode
+	targets   []Target
+	outputDir string
+	appBase   string
+
+	excludes       *matcher
+	debounceWindow time.Duration
+	hooks          *Hooks
+
+	gracefulTimeout time.Duration
+	readiness       *ReadinessProbe
+
+	// rebuildMux 保证同一时刻只有一次 rebuild 在执行，见 rebuild。
+	rebuildMux sync.Mutex
+
+	// cmdMux 保护 cmd 和 exited 这两个字段：cmd.Wait() 只由 startApp
+	// 启动的那一个 goroutine 调用一次，stopGracefully、
+	// forwardSignals 都只读取这两个字段、通过 exited 等待结果，
+	// 不会再对同一个 *exec.Cmd 调用第二次 Wait。
+	cmdMux sync.Mutex
+	cmd    *exec.Cmd
+	exited chan struct{}
+
+	db *debouncer
+}
+
+// initWatcher 创建一个监视 paths 下文件改变的 fsnotify.Watcher。
+func (b *builder) initWatcher(paths []string) (*fsnotify.Watcher, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range paths {
+		if err := w.Add(p); err != nil {
+			w.Close()
+			return nil, err
+		}
+	}
+
+	return w, nil
+}
+
+// watch 启动一个 goroutine 持续读取 w 产生的事件：先依据扩展名和
+// b.excludes 过滤掉不关心的文件，再交给 debouncer 合并同一批事件、
+// 按内容哈希判断是否真的发生了变化，最终通过 rebuild 触发一次新的
+// 编译，取代了过去每次 fsnotify 事件都直接编译的做法。
+func (b *builder) watch(w *fsnotify.Watcher) {
+	b.db = b.newDebouncer()
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if b.ignoreEvent(event.Name) {
+					continue
+				}
+				b.db.add(event.Name)
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				b.logs <- &Log{Type: LogTypeError, Message: fmt.Sprint("监视文件失败：", err)}
+			}
+		}
+	}()
+}
+
+// ignoreEvent 判断 name 是否应该被忽略：扩展名不在 b.exts 中，
+// 或是命中了 b.excludes 中的排除规则。
+func (b *builder) ignoreEvent(name string) bool {
+	if b.shouldIgnore(name) {
+		return true
+	}
+
+	for _, ext := range b.exts {
+		if ext == "*" || filepath.Ext(name) == ext {
+			return false
+		}
+	}
+	return true
+}
+
+// rebuild 是 debouncer 确认内容真正发生变化之后的入口，根据当前
+// builder 所处的模式分发到对应的编译流程：交叉编译目标、库模式，
+// 或是默认的 main 包编译，每一次文件内容变化都会重新调用一次，
+// 而不仅仅是 Build/BuildCross/BuildLibrary 启动时的那一次。
+//
+// rebuildMux 保证同一时刻只有一次 rebuild 在执行：启动时的首次
+// 调用可能还没结束，下一次 debounce 触发的 rebuild 就已经到来，
+// 两者若并发执行，会在未加锁的 b.cmd、b.appName 等字段上产生
+// 竞争，还可能并发跑出两次 go build -o 同一输出文件、或两份
+// startApp。加锁后，后到的调用会排队等到前一次完全结束再执行。
+func (b *builder) rebuild() {
+	b.rebuildMux.Lock()
+	defer b.rebuildMux.Unlock()
+
+	switch {
+	case len(b.targets) > 0:
+		b.buildTargets()
+	case b.mode != ModeApp:
+		b.buildLibrary()
+	default:
+		b.runPipeline(b.build, b.runApp)
+	}
+}
+
+// runApp 包装 startApp，使其满足 runPipeline 所要求的 func() error
+// 签名；startApp 本身的失败已经通过 logs 上报，这里不需要重复处理。
+func (b *builder) runApp() error {
+	b.startApp()
+	return nil
+}
+
+// args 按当前的 rawFlags 重新展开模板并组装一次完整的 go 命令参数。
+// 每次调用都会重新求值，因此 {{.Git.Commit}} 这类变量在每次 rebuild
+// 时都能拿到最新值，而不是像过去那样只在 Build/BuildCross/
+// BuildLibrary 启动时展开一次、此后所有编译都复用同一份冻结的参数。
+func (b *builder) args(output string) []string {
+	flags, err := flagsTemplate(b.wd, b.rawFlags, nil)
+	if err != nil {
+		b.logs <- &Log{
+			Type:    LogTypeWarn,
+			Message: fmt.Sprint("ldflags 模板展开失败，将使用原始值：", err),
+		}
+		flags = b.rawFlags
+	}
+
+	args := []string{b.verb}
+	args = append(args, b.extraVerbArgs...)
+	if output != "" {
+		args = append(args, "-o", output)
+	}
+	for k, v := range flags {
+		args = append(args, "-"+k+"flags", v)
+	}
+	args = append(args, "-v")
+
+	if len(b.mainFiles) > 0 {
+		args = append(args, b.mainFiles)
+	} else if b.defaultToAll {
+		args = append(args, "./...")
+	}
+
+	return args
+}
+
+// build 对 main 包执行一次 go build，参数由 args 组装。
+func (b *builder) build() error {
+	args := b.args(b.appName)
+
+	cmd := exec.Command("go", args...)
+	cmd.Dir = b.wd
+	out, err := cmd.CombinedOutput()
+	if len(out) > 0 {
+		b.logs <- &Log{Type: LogTypeInfo, Message: string(out)}
+	}
+	if err != nil {
+		b.logs <- &Log{Type: LogTypeError, Message: fmt.Sprint("编译失败：", err)}
+		return err
+	}
+
+	b.logs <- &Log{Type: LogTypeSuccess, Message: "编译成功"}
+	return nil
+}
+
+// startApp 在编译成功之后（重新）启动 b.appName：启动前会优雅地
+// 终止上一个仍在运行的实例，启动后驱动就绪探测，并在进程退出时
+// 上报退出码，取代了过去没有任何进程生命周期管理的空白。
+func (b *builder) startApp() {
+	b.stopGracefully(b.gracefulTimeout)
+
+	cmd := exec.Command(b.appName, b.appArgs...)
+	cmd.Dir = b.wd
+	cmd.Stdout = logWriter{logs: b.logs, typ: LogTypeInfo}
+	cmd.Stderr = logWriter{logs: b.logs, typ: LogTypeError}
+
+	if err := cmd.Start(); err != nil {
+		b.logs <- &Log{Type: LogTypeError, Message: fmt.Sprint("启动失败：", err)}
+		return
+	}
+
+	exited := make(chan struct{})
+	b.cmdMux.Lock()
+	b.cmd = cmd
+	b.exited = exited
+	b.cmdMux.Unlock()
+
+	go b.waitReady(b.readiness, 0)
+
+	// cmd.Wait 在整个生命周期里只在这一个 goroutine 里调用一次，
+	// stopGracefully 只等待 exited 被关闭，不会自己再调用一次 Wait。
+	go func() {
+		err := cmd.Wait()
+		code := 0
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			code = exitErr.ExitCode()
+		}
+		close(exited)
+		b.reportExit(code)
+	}()
+}
+
+// logWriter 将每一次 Write 调用的内容作为一条 Log 发送到 logs，
+// 用于捕获子进程的 stdout/stderr。
+type logWriter struct {
+	logs chan *Log
+	typ  LogType
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	w.logs <- &Log{Type: w.typ, Message: string(p)}
+	return len(p), nil
+}
@@ -0,0 +1,35 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package gobuild
+
+import (
+	"testing"
+	"time"
+)
+
+// TestBuilder_RestartTwiceDoesNotRaceCmdWait 驱动 startApp/
+// stopGracefully 组成的真实重启路径，快速重启两次短生命周期的子
+// 进程：第二次 startApp 会通过 stopGracefully 终止第一次启动的
+// 进程。用 go test -race 运行本测试，用于确认 cmd.Wait 只有一个
+// 调用者（见 builder.cmdMux/exited），不会在同一个 *exec.Cmd 上
+// 并发 Wait 两次。
+func TestBuilder_RestartTwiceDoesNotRaceCmdWait(t *testing.T) {
+	b := &builder{
+		appName: "sh",
+		appArgs: []string{"-c", "sleep 0.2"},
+		logs:    make(chan *Log, 100),
+	}
+
+	go func() {
+		for range b.logs {
+		}
+	}()
+
+	b.startApp()
+	time.Sleep(20 * time.Millisecond)
+	b.startApp() // 应终止第一个进程并等待其 Wait 完成，不与其并发
+
+	time.Sleep(300 * time.Millisecond) // 等待第二个进程自然退出
+}
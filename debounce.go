@@ -0,0 +1,132 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package gobuild
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// defaultDebounce 是未显式设置 debounce 窗口时使用的默认值，
+// 编辑器保存文件时产生的 WRITE/CREATE/RENAME 事件大多会落在这个
+// 窗口内，从而被合并成一次编译。
+const defaultDebounce = 300 * time.Millisecond
+
+// debouncer 负责把短时间内的多次文件改变事件合并为一次编译请求，
+// 并在内容哈希未变化时跳过这次编译。
+type debouncer struct {
+	mux     sync.Mutex
+	window  time.Duration
+	timer   *time.Timer
+	pending map[string]bool   // 本批次中被改动的文件
+	hashes  map[string]string // 上一次 flush 时记录的每个文件的内容哈希，不论编译是否成功
+	trigger func(changed []string)
+}
+
+// newDebouncer 创建一个 debouncer，window <= 0 时使用 defaultDebounce。
+// trigger 在窗口到期、且至少有一个文件的内容哈希发生变化时被调用。
+func newDebouncer(window time.Duration, trigger func(changed []string)) *debouncer {
+	if window <= 0 {
+		window = defaultDebounce
+	}
+
+	return &debouncer{
+		window:  window,
+		pending: make(map[string]bool),
+		hashes:  make(map[string]string),
+		trigger: trigger,
+	}
+}
+
+// add 记录一次文件改变事件，并（重新）启动 debounce 计时器。
+func (d *debouncer) add(path string) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	d.pending[path] = true
+
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.timer = time.AfterFunc(d.window, d.flush)
+}
+
+// flush 是计时器到期后的回调，计算本批次中每个文件的内容哈希，
+// 过滤掉哈希未变化的文件，如果还有文件发生了真正的内容变化，
+// 则调用 trigger。
+func (d *debouncer) flush() {
+	d.mux.Lock()
+	paths := make([]string, 0, len(d.pending))
+	for p := range d.pending {
+		paths = append(paths, p)
+	}
+	d.pending = make(map[string]bool)
+	d.mux.Unlock()
+
+	changed := make([]string, 0, len(paths))
+	for _, p := range paths {
+		sum, err := fileSHA1(p)
+		if err != nil {
+			// 文件可能已被删除或重命名，仍视为一次有效改变。
+			changed = append(changed, p)
+			continue
+		}
+
+		d.mux.Lock()
+		old, ok := d.hashes[p]
+		d.hashes[p] = sum
+		d.mux.Unlock()
+
+		if !ok || old != sum {
+			changed = append(changed, p)
+		}
+	}
+
+	if len(changed) > 0 {
+		d.trigger(changed)
+	}
+}
+
+// newDebouncer 为 b 创建一个 debouncer，使用 b.debounceWindow 作为
+// 合并窗口（零值时退化为 defaultDebounce），每次真正触发编译前，
+// 都会向 logs 输出一条消息说明本批次中内容确实发生变化的文件列表。
+func (b *builder) newDebouncer() *debouncer {
+	return newDebouncer(b.debounceWindow, func(changed []string) {
+		b.logs <- &Log{
+			Type:    LogTypeInfo,
+			Message: sprintChanged(changed),
+		}
+		b.rebuild()
+	})
+}
+
+func sprintChanged(changed []string) string {
+	msg := "检测到以下文件内容发生变化，重新编译：\n"
+	for _, c := range changed {
+		msg += "  " + c + "\n"
+	}
+	return msg
+}
+
+// fileSHA1 计算文件内容的 sha1，用于判断改变事件是否带来了真正
+// 的内容变化，以此过滤掉只改变了 mtime 的事件。
+func fileSHA1(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha1.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
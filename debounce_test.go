@@ -0,0 +1,66 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package gobuild
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestDebouncer_Flush 验证 flush 的内容哈希过滤逻辑：同一文件未发生
+// 真正内容变化时不应重复触发 trigger，内容变化或文件被删除时才触发。
+func TestDebouncer_Flush(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "a.go")
+	if err := os.WriteFile(file, []byte("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var triggered int
+	d := newDebouncer(time.Millisecond, func(changed []string) {
+		triggered++
+	})
+
+	// 第一次 flush：此前没有记录过该文件的哈希，视为变化。
+	d.pending[file] = true
+	d.flush()
+	if triggered != 1 {
+		t.Fatalf("首次 flush 应该触发一次，got %d", triggered)
+	}
+
+	// 内容未变化时再次 flush，不应触发。
+	d.pending[file] = true
+	d.flush()
+	if triggered != 1 {
+		t.Fatalf("内容未变化不应触发，got %d", triggered)
+	}
+
+	// 修改文件内容后 flush，应重新触发。
+	if err := os.WriteFile(file, []byte("v2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d.pending[file] = true
+	d.flush()
+	if triggered != 2 {
+		t.Fatalf("内容变化应触发，got %d", triggered)
+	}
+
+	// 文件被删除时，fileSHA1 出错，仍视为一次有效变化。
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	d.pending[file] = true
+	d.flush()
+	if triggered != 3 {
+		t.Fatalf("文件被删除应视为变化并触发，got %d", triggered)
+	}
+
+	// pending 为空时 flush 不应触发。
+	d.flush()
+	if triggered != 3 {
+		t.Fatalf("没有 pending 文件时不应触发，got %d", triggered)
+	}
+}
@@ -27,10 +27,14 @@ import (
 //  - gccgo --> gccgoflags
 //  - gc    --> gcflags
 //  - ld    --> ldflags
+// 其中的值支持 {{.Git.Commit}}、{{.Date}} 等模板变量，参考
+// flagsTemplate，具体的重新展开时机见 builder.args；
 // dir 表示需要监视的目录，至少指定一个目录，第一个目录被当作主目录，将编译其下的文件。
 //
 // 工作路径：如果 outputName 带路径信息，则会使用该文件所在目录作为工作目录，
 // 如果未指定或是仅是一个文件名，则采用 dir 中的第一个参数作为其工作目录。
+//
+// 若需要以 gitignore 风格的规则排除部分文件或目录，请使用 BuildWithExcludes。
 func Build(logs chan *Log,
 	mainFiles string,
 	outputName string,
@@ -39,6 +43,37 @@ func Build(logs chan *Log,
 	recursive bool,
 	appArgs string,
 	dir ...string) error {
+	return buildApp(logs, mainFiles, outputName, flags, exts, recursive, nil, appArgs, dir...)
+}
+
+// BuildWithExcludes 与 Build 功能完全相同，额外接受 excludes 参数。
+//
+// excludes 为 gitignore 风格的排除规则，遍历子目录以及处理 watcher
+// 事件时，命中任意一条规则的文件或目录都会被忽略；遍历过程中遇到的
+// .gitignore、.gobuildignore 文件也会被自动加载，无需显式传入。
+func BuildWithExcludes(logs chan *Log,
+	mainFiles string,
+	outputName string,
+	flags map[string]string,
+	exts string,
+	recursive bool,
+	excludes []string,
+	appArgs string,
+	dir ...string) error {
+	return buildApp(logs, mainFiles, outputName, flags, exts, recursive, excludes, appArgs, dir...)
+}
+
+// buildApp 是 Build 和 BuildWithExcludes 的共同实现，excludes 为
+// nil 时等同于不排除任何文件。
+func buildApp(logs chan *Log,
+	mainFiles string,
+	outputName string,
+	flags map[string]string,
+	exts string,
+	recursive bool,
+	excludes []string,
+	appArgs string,
+	dir ...string) error {
 	if len(dir) < 1 {
 		return errors.New("参数 dir 至少指定一个")
 	}
@@ -53,14 +88,9 @@ func Build(logs chan *Log,
 		return err
 	}
 
-	// 初始化 goCmd 的参数
-	args := []string{"build", "-o", appName}
-	for k, v := range flags {
-		args = append(args, "-"+k+"flags", v)
-	}
-	args = append(args, "-v")
-	if len(mainFiles) > 0 {
-		args = append(args, mainFiles)
+	paths, m, err := recursivePathsWithExcludes(recursive, dir, excludes)
+	if err != nil {
+		return err
 	}
 
 	b := &builder{
@@ -68,8 +98,11 @@ func Build(logs chan *Log,
 		appName:   appName,
 		wd:        filepath.Dir(appName),
 		appArgs:   splitArgs(appArgs),
-		goCmdArgs: args,
+		verb:      "build",
+		mainFiles: mainFiles,
+		rawFlags:  flags,
 		logs:      logs,
+		excludes:  m,
 	}
 
 	// 输出提示信息
@@ -98,10 +131,6 @@ func Build(logs chan *Log,
 		Message: fmt.Sprint("输出文件为:", b.appName),
 	}
 
-	paths, err := recursivePaths(recursive, dir)
-	if err != nil {
-		return err
-	}
 	w, err := b.initWatcher(paths)
 	if err != nil {
 		return err
@@ -109,7 +138,8 @@ func Build(logs chan *Log,
 	defer w.Close()
 
 	b.watch(w)
-	go b.build()
+	b.forwardSignals()
+	go b.rebuild()
 
 	<-make(chan bool)
 	return nil
@@ -181,34 +211,6 @@ func appendArg(args []string, arg string) []string {
 	return append(args, arg)
 }
 
-// 根据 recursive 值确定是否递归查找 paths 每个目录下的子目录。
-func recursivePaths(recursive bool, paths []string) ([]string, error) {
-	if !recursive {
-		return paths, nil
-	}
-
-	ret := []string{}
-
-	walk := func(path string, fi os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		if fi.IsDir() && strings.Index(path, "/.") < 0 {
-			ret = append(ret, path)
-		}
-		return nil
-	}
-
-	for _, path := range paths {
-		if err := filepath.Walk(path, walk); err != nil {
-			return nil, err
-		}
-	}
-
-	return ret, nil
-}
-
 // 将 extString 分解成数组，并清理掉无用的内容，比如空字符串
 func getExts(extString string) []string {
 	exts := strings.Split(extString, ",")
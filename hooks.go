@@ -0,0 +1,153 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package gobuild
+
+import (
+	"bufio"
+	"fmt"
+	"os/exec"
+	"sync"
+)
+
+// Hooks 定义了在一次编译-运行周期中各个阶段可以执行的命令，
+// 每一项都是一条完整的 shell 命令，比如 "go generate ./..."，
+// 按声明顺序依次执行。
+type Hooks struct {
+	// PreBuild 在 go build 之前执行，典型用途是代码生成，
+	// 如 go generate ./...、sqlc generate、templ generate、wire。
+	PreBuild []string
+
+	// PostBuild 在编译成功之后、重启程序之前执行，典型用途是
+	// 复制静态资源、生成文档等。
+	PostBuild []string
+
+	// PreRun 在启动新的子进程之前执行。
+	PreRun []string
+
+	// PostStop 在旧的子进程被终止之后执行，典型用途是清理临时
+	// 文件、运行数据库回滚等。
+	PostStop []string
+}
+
+// runHooks 依次执行 cmds 中的每一条命令，并把输出通过 logs 传递
+// 出去。一旦某条命令执行失败，会立即输出 LogTypeError 并中止剩余
+// 命令的执行，调用方应视为本次编译-运行周期失败。
+func runHooks(logs chan *Log, wd string, stage string, cmds []string) error {
+	for _, c := range cmds {
+		if err := runHook(logs, wd, stage, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runHook 通过 sh -c 执行单条 hook 命令，stdout 和 stderr 都会
+// 按行输出到 logs，并带上所属阶段的标记，方便在混合日志中区分。
+func runHook(logs chan *Log, wd string, stage string, command string) error {
+	logs <- &Log{
+		Type:    LogTypeInfo,
+		Message: fmt.Sprintf("[%s] 执行：%s", stage, command),
+	}
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Dir = wd
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		logs <- &Log{
+			Type:    LogTypeError,
+			Message: fmt.Sprintf("[%s] 命令执行失败：%s：%s", stage, command, err),
+		}
+		return err
+	}
+
+	// 必须先读完 stdout、stderr 再调用 Wait，Wait 会关闭这两个管道。
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		pipeToLog(logs, stage, stdout)
+	}()
+	go func() {
+		defer wg.Done()
+		pipeToLog(logs, stage, stderr)
+	}()
+	wg.Wait()
+
+	if err := cmd.Wait(); err != nil {
+		logs <- &Log{
+			Type:    LogTypeError,
+			Message: fmt.Sprintf("[%s] 命令执行失败：%s：%s", stage, command, err),
+		}
+		return err
+	}
+
+	return nil
+}
+
+func pipeToLog(logs chan *Log, stage string, r interface {
+	Read(p []byte) (int, error)
+}) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		logs <- &Log{
+			Type:    LogTypeInfo,
+			Message: fmt.Sprintf("[%s] %s", stage, scanner.Text()),
+		}
+	}
+}
+
+// runPipeline 按 PreBuild -> build -> PostBuild -> PreRun -> run
+// 的顺序驱动一次完整的编译-运行周期；do 为实际执行编译的函数，
+// doRun 为启动子进程的函数。只要任意一个 hook 阶段失败，整个周期
+// 都会被中止，不会进入后续阶段。
+func (b *builder) runPipeline(do func() error, doRun func() error) error {
+	if b.hooks == nil {
+		if err := do(); err != nil {
+			return err
+		}
+		return doRun()
+	}
+
+	if err := runHooks(b.logs, b.wd, "pre-build", b.hooks.PreBuild); err != nil {
+		return err
+	}
+
+	if err := do(); err != nil {
+		return err
+	}
+
+	if err := runHooks(b.logs, b.wd, "post-build", b.hooks.PostBuild); err != nil {
+		return err
+	}
+
+	if err := runHooks(b.logs, b.wd, "pre-run", b.hooks.PreRun); err != nil {
+		return err
+	}
+
+	return doRun()
+}
+
+// runPostStop 在子进程停止之后调用，用于驱动 PostStop 阶段的 hook。
+func (b *builder) runPostStop() {
+	if b.hooks == nil || len(b.hooks.PostStop) == 0 {
+		return
+	}
+
+	if err := runHooks(b.logs, b.wd, "post-stop", b.hooks.PostStop); err != nil {
+		b.logs <- &Log{
+			Type:    LogTypeError,
+			Message: fmt.Sprint("post-stop 阶段执行失败：", err),
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package gobuild
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFiles 是在目录遍历过程中会被自动加载并入排除规则的文件名。
+var ignoreFiles = []string{".gitignore", ".gobuildignore"}
+
+// matcher 保存了一组 gitignore 风格的排除规则，用于在遍历目录
+// 和处理 watcher 事件时过滤掉不需要关心的文件或目录。
+type matcher struct {
+	patterns []string
+}
+
+// newMatcher 根据用户传入的 excludes 创建一个 matcher，excludes
+// 中的每一项都是一个 gitignore 风格的 glob，比如 vendor/、*_gen.go。
+func newMatcher(excludes []string) *matcher {
+	m := &matcher{patterns: make([]string, 0, len(excludes))}
+	for _, e := range excludes {
+		m.add(e)
+	}
+	return m
+}
+
+// add 追加一条规则，空行和以 # 开头的注释行会被忽略，与 gitignore
+// 的语法保持一致。
+func (m *matcher) add(pattern string) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return
+	}
+	m.patterns = append(m.patterns, pattern)
+}
+
+// loadFile 读取 path 指向的 ignore 文件（如 .gitignore），将其中
+// 的每一行追加为一条规则。文件不存在时直接返回，不视为错误。
+func (m *matcher) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		m.add(scanner.Text())
+	}
+	return scanner.Err()
+}
+
+// match 判断 rel（相对于被遍历的根目录的路径，使用 / 分隔）是否
+// 命中任意一条排除规则。
+func (m *matcher) match(rel string) bool {
+	rel = filepath.ToSlash(rel)
+	base := filepath.Base(rel)
+
+	for _, p := range m.patterns {
+		p = strings.TrimSuffix(p, "/")
+
+		if ok, _ := filepath.Match(p, base); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(p, rel); ok {
+			return true
+		}
+		if rel == p || strings.HasPrefix(rel, p+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// shouldIgnore 供 watch 在收到 fsnotify 事件时调用，判断 path 是否
+// 命中 b.excludes 中的任一规则，从而避免 vendor/、node_modules/、
+// dist/ 以及生成的 *_gen.go 等文件触发不必要的重新编译。
+func (b *builder) shouldIgnore(path string) bool {
+	if b.excludes == nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(b.wd, path)
+	if err != nil {
+		rel = path
+	}
+	return b.excludes.match(rel)
+}
+
+// recursivePathsWithExcludes 根据 recursive 值确定是否递归查找 paths
+// 每个目录下的子目录，使用 matcher 取代原本仅判断路径中是否含 "/."
+// 的简单规则：会自动加载
+// 遍历到的 .gitignore、.gobuildignore，并应用用户传入的 excludes。
+//
+// 遍历过程中加载到的规则都会累积在返回的 matcher 上，调用方应将其
+// 保存为 b.excludes，供 shouldIgnore 在处理后续的 watcher 事件时
+// 复用，而不是另外用原始的 excludes 参数重新创建一个不包含
+// .gitignore/.gobuildignore 规则的 matcher。
+func recursivePathsWithExcludes(recursive bool, paths []string, excludes []string) ([]string, *matcher, error) {
+	m := newMatcher(excludes)
+
+	if !recursive {
+		return paths, m, nil
+	}
+
+	ret := []string{}
+
+	for _, root := range paths {
+		walk := func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if !fi.IsDir() {
+				return nil
+			}
+
+			for _, name := range ignoreFiles {
+				if loadErr := m.loadFile(filepath.Join(path, name)); loadErr != nil {
+					return loadErr
+				}
+			}
+
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			if rel != "." && (strings.HasPrefix(filepath.Base(path), ".") || m.match(rel)) {
+				return filepath.SkipDir
+			}
+
+			ret = append(ret, path)
+			return nil
+		}
+
+		if err := filepath.Walk(root, walk); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return ret, m, nil
+}
@@ -0,0 +1,48 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package gobuild
+
+import "testing"
+
+func TestMatcher_Match(t *testing.T) {
+	m := newMatcher([]string{"vendor/", "*.log", "dist", "# 注释会被忽略", "", "node_modules/foo"})
+
+	cases := []struct {
+		rel  string
+		want bool
+	}{
+		{"vendor/pkg/a.go", true},   // 命中目录规则 vendor/
+		{"vendor", true},            // 目录本身
+		{"a.log", true},             // 命中 *.log
+		{"sub/a.log", true},         // base 匹配同样生效
+		{"dist/app", true},          // 命中 dist 前缀
+		{"dist", true},
+		{"node_modules/foo", true},  // 命中带路径的规则
+		{"node_modules/bar", false}, // 同级但不同名的目录不应命中
+		{"main.go", false},
+		{"sub/main.go", false},
+	}
+
+	for _, c := range cases {
+		if got := m.match(c.rel); got != c.want {
+			t.Errorf("match(%q) = %v, want %v", c.rel, got, c.want)
+		}
+	}
+}
+
+func TestMatcher_Add_IgnoresCommentsAndBlankLines(t *testing.T) {
+	m := newMatcher(nil)
+	m.add("# comment")
+	m.add("")
+	m.add("  ")
+	m.add("*.tmp")
+
+	if len(m.patterns) != 1 {
+		t.Fatalf("got %d patterns, want 1: %v", len(m.patterns), m.patterns)
+	}
+	if !m.match("a.tmp") {
+		t.Fatalf("*.tmp 规则应该命中 a.tmp")
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package gobuild
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// gitInfo 保存从工作目录解析出来的 git 元数据，供 ldflags 模板使用。
+type gitInfo struct {
+	Commit string // 完整的 commit hash
+	Short  string // 短 commit hash
+	Tag    string // 离 HEAD 最近的 tag，不存在时为空字符串
+	Branch string // 当前分支名称
+	Dirty  bool   // 工作区是否存在未提交的改动
+}
+
+// tmplContext 是 flagsTemplate 解析模板时使用的顶层数据，
+// 对应 -X main.Version={{.Git.Commit}} 这类写法。
+type tmplContext struct {
+	Git   gitInfo
+	Date  string // RFC3339 格式的当前时间
+	Host  string // 运行 gobuild 的机器的 hostname
+	GoVer string // go version 的输出结果
+	Env   map[string]string
+}
+
+// flagsTemplate 将 flags 中的每一个值作为 text/template 模板解析，
+// 并使用从 wd 解析出的 git 信息、当前时间等数据渲染，返回渲染后的新
+// map。flags 本身不会被修改。
+//
+// 典型用法：
+//  flags["ld"] = "-X main.Version={{.Git.Commit}} -X main.BuildDate={{.Date}}"
+// 每次重新编译时，Version 和 BuildDate 都会使用最新的值。
+func flagsTemplate(wd string, flags map[string]string, extra map[string]string) (map[string]string, error) {
+	if len(flags) == 0 {
+		return flags, nil
+	}
+
+	ctx := tmplContext{
+		Git:   resolveGitInfo(wd),
+		Date:  time.Now().Format(time.RFC3339),
+		GoVer: goVersion(),
+		Env:   extra,
+	}
+	if host, err := os.Hostname(); err == nil {
+		ctx.Host = host
+	}
+
+	ret := make(map[string]string, len(flags))
+	for k, v := range flags {
+		if !strings.Contains(v, "{{") {
+			ret[k] = v
+			continue
+		}
+
+		t, err := template.New(k).Parse(v)
+		if err != nil {
+			return nil, err
+		}
+
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, ctx); err != nil {
+			return nil, err
+		}
+		ret[k] = buf.String()
+	}
+
+	return ret, nil
+}
+
+// resolveGitInfo 在 wd 下调用 git 命令获取仓库的元数据，任何一步
+// 出错都会返回零值，不中断编译流程，因为并非所有项目都处于 git 仓库中。
+func resolveGitInfo(wd string) gitInfo {
+	var info gitInfo
+
+	info.Commit = runGit(wd, "rev-parse", "HEAD")
+	info.Short = runGit(wd, "rev-parse", "--short", "HEAD")
+	info.Branch = runGit(wd, "rev-parse", "--abbrev-ref", "HEAD")
+	info.Tag = runGit(wd, "describe", "--tags", "--abbrev=0")
+	info.Dirty = runGit(wd, "status", "--porcelain") != ""
+
+	return info
+}
+
+func runGit(wd string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = wd
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func goVersion() string {
+	out, err := exec.Command("go", "version").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
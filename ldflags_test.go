@@ -0,0 +1,82 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package gobuild
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFlagsTemplate(t *testing.T) {
+	wd := t.TempDir() // 非 git 仓库，resolveGitInfo 应返回零值
+
+	cases := []struct {
+		name  string
+		flags map[string]string
+		check func(t *testing.T, ret map[string]string)
+	}{
+		{
+			name:  "空 flags 原样返回",
+			flags: nil,
+			check: func(t *testing.T, ret map[string]string) {
+				if ret != nil {
+					t.Fatalf("got %v, want nil", ret)
+				}
+			},
+		},
+		{
+			name:  "不含模板变量的值原样返回",
+			flags: map[string]string{"gc": "-N -l"},
+			check: func(t *testing.T, ret map[string]string) {
+				if ret["gc"] != "-N -l" {
+					t.Fatalf("got %q, want %q", ret["gc"], "-N -l")
+				}
+			},
+		},
+		{
+			name:  "非 git 仓库下 {{.Git.Commit}} 展开为空字符串",
+			flags: map[string]string{"ld": "-X main.Version={{.Git.Commit}}"},
+			check: func(t *testing.T, ret map[string]string) {
+				if ret["ld"] != "-X main.Version=" {
+					t.Fatalf("got %q, want %q", ret["ld"], "-X main.Version=")
+				}
+			},
+		},
+		{
+			name:  "{{.Date}} 展开为非空且不再包含模板语法",
+			flags: map[string]string{"ld": "-X main.BuildDate={{.Date}}"},
+			check: func(t *testing.T, ret map[string]string) {
+				if strings.Contains(ret["ld"], "{{") {
+					t.Fatalf("模板未被展开: %q", ret["ld"])
+				}
+				if ret["ld"] == "-X main.BuildDate=" {
+					t.Fatalf("Date 不应为空")
+				}
+			},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			ret, err := flagsTemplate(wd, c.flags, nil)
+			if err != nil {
+				t.Fatalf("flagsTemplate 返回错误: %v", err)
+			}
+			c.check(t, ret)
+		})
+	}
+}
+
+func TestResolveGitInfo_NonGitDir(t *testing.T) {
+	info := resolveGitInfo(t.TempDir())
+
+	if info.Commit != "" || info.Short != "" || info.Tag != "" {
+		t.Fatalf("非 git 目录下 git 信息应为空值，got %+v", info)
+	}
+	if info.Dirty {
+		t.Fatalf("git status 执行失败时 Dirty 应为 false")
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package gobuild
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Mode 用于区分 gobuild 的工作模式。
+type Mode int8
+
+const (
+	// ModeApp 为默认模式，go build 生成的可执行文件会在每次编译
+	// 成功之后被重新启动，适用于 main 包。
+	ModeApp Mode = iota
+
+	// ModeLibrary 用于非 main 包：文件改变时只执行编译（默认为
+	// go build ./...），不会尝试运行生成的产物，也不会有 -o 参数。
+	ModeLibrary
+
+	// ModeTest 与 ModeLibrary 类似，但执行的是 go test -c，
+	// 用于只关心测试包是否能正常编译的场景。
+	ModeTest
+
+	// ModeVet 执行 go vet ./...，用于只想在保存时做静态检查的场景。
+	ModeVet
+)
+
+// BuildLibrary 提供与 Build 相同的监视-编译循环，但用于非 main 包：
+// 文件发生变化时只执行编译（或 go test -c / go vet，由 mode 决定），
+// 不会启动任何进程，编译错误通过 logs 输出。
+//
+// mode 只能是 ModeLibrary、ModeTest 或 ModeVet 三者之一，ModeApp
+// 请直接使用 Build。excludes 为 gitignore 风格的排除规则，含义与
+// BuildWithExcludes 中的同名参数一致，可以为空。mainFiles 为空时
+// 编译 ./...，而不是 Build 中的当前目录。其余参数的含义与 Build
+// 保持一致。
+func BuildLibrary(logs chan *Log,
+	mode Mode,
+	mainFiles string,
+	flags map[string]string,
+	exts string,
+	recursive bool,
+	excludes []string,
+	dir ...string) error {
+	if mode == ModeApp {
+		return fmt.Errorf("mode 不能为 ModeApp，请使用 Build")
+	}
+	if len(dir) < 1 {
+		return fmt.Errorf("参数 dir 至少指定一个")
+	}
+
+	wd, err := filepath.Abs(dir[0])
+	if err != nil {
+		return err
+	}
+	dir[0] = wd
+
+	verb, extraVerbArgs := libraryVerb(mode)
+
+	paths, m, err := recursivePathsWithExcludes(recursive, dir, excludes)
+	if err != nil {
+		return err
+	}
+
+	b := &builder{
+		exts:          getExts(exts),
+		wd:            wd,
+		mode:          mode,
+		verb:          verb,
+		extraVerbArgs: extraVerbArgs,
+		mainFiles:     mainFiles,
+		defaultToAll:  true,
+		rawFlags:      flags,
+		logs:          logs,
+		excludes:      m,
+	}
+
+	logs <- &Log{
+		Type:    LogTypeInfo,
+		Message: fmt.Sprint("以库模式运行，不会启动任何进程：", modeName(mode)),
+	}
+
+	w, err := b.initWatcher(paths)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	b.watch(w)
+	b.forwardSignals()
+	go b.rebuild()
+
+	<-make(chan bool)
+	return nil
+}
+
+// libraryVerb 根据 mode 返回对应的 go 子命令及紧跟其后的固定参数，
+// 供 builder.args 组装完整命令行，库模式与测试模式都不需要 -o
+// 参数，因为产物不会被执行（ModeTest 的 -o 由 buildLibrary 单独传入
+// os.DevNull 丢弃）。
+func libraryVerb(mode Mode) (verb string, extraVerbArgs []string) {
+	switch mode {
+	case ModeTest:
+		return "test", []string{"-c"}
+	case ModeVet:
+		return "vet", nil
+	default: // ModeLibrary
+		return "build", nil
+	}
+}
+
+// buildLibrary 执行一次库模式编译，只报告结果，不会触发 restart；
+// 编译前后会分别驱动 PreBuild、PostBuild 钩子，参数由 b.args 组装。
+func (b *builder) buildLibrary() {
+	if b.hooks != nil {
+		if err := runHooks(b.logs, b.wd, "pre-build", b.hooks.PreBuild); err != nil {
+			return
+		}
+	}
+
+	output := ""
+	if b.mode == ModeTest {
+		output = os.DevNull
+	}
+	args := b.args(output)
+
+	cmd := exec.Command("go", args...)
+	cmd.Dir = b.wd
+	out, err := cmd.CombinedOutput()
+	if len(out) > 0 {
+		b.logs <- &Log{Type: LogTypeInfo, Message: string(out)}
+	}
+	if err != nil {
+		b.logs <- &Log{
+			Type:    LogTypeError,
+			Message: fmt.Sprint("编译失败：", err),
+		}
+		return
+	}
+
+	if b.hooks != nil {
+		if err := runHooks(b.logs, b.wd, "post-build", b.hooks.PostBuild); err != nil {
+			return
+		}
+	}
+
+	b.logs <- &Log{
+		Type:    LogTypeSuccess,
+		Message: "编译成功",
+	}
+}
+
+func modeName(mode Mode) string {
+	switch mode {
+	case ModeTest:
+		return "go test -c"
+	case ModeVet:
+		return "go vet"
+	default:
+		return "go build"
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package gobuild
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// defaultGracefulTimeout 是未显式设置 GracefulTimeout 时使用的
+// 默认值：给子进程 5 秒时间自行退出，超时后强制 SIGKILL。
+const defaultGracefulTimeout = 5 * time.Second
+
+// ReadinessProbe 描述了如何判断子进程已经启动完毕、可以对外提供
+// 服务。Addr 为空时不会探测，waitReady 会直接输出 Ready 日志。
+type ReadinessProbe struct {
+	// Addr 为 host:port 形式的地址。
+	Addr string
+
+	// HTTP 不为空时，使用 HTTP GET 请求该路径（相对于 Addr）判断
+	// 是否就绪，返回 2xx 视为就绪；为空时仅做 TCP 拨号探测。
+	HTTP string
+
+	// Timeout 为单次探测的超时时间，默认为 1 秒。
+	Timeout time.Duration
+
+	// Interval 为两次探测之间的间隔，默认为 100 毫秒。
+	Interval time.Duration
+}
+
+// forwardSignals 将 gobuild 自身收到的 SIGINT/SIGTERM 转发给
+// 当前正在运行的子进程，再退出自身进程，以保证子进程不会被孤立。
+func (b *builder) forwardSignals() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		sig := <-ch
+
+		b.cmdMux.Lock()
+		cmd := b.cmd
+		b.cmdMux.Unlock()
+
+		if cmd != nil && cmd.Process != nil {
+			cmd.Process.Signal(sig)
+		}
+		os.Exit(0)
+	}()
+}
+
+// stopGracefully 向正在运行的子进程发送 SIGTERM，最多等待
+// timeout（<=0 时使用 defaultGracefulTimeout）让其自行退出，超时
+// 后发送 SIGKILL。子进程退出后会输出 LogTypeKilled 日志。
+//
+// cmd.Wait 由 startApp 启动的那一个 goroutine 独占调用，这里只
+// 通过 exited 等待其结果，不会再调用一次 Wait：对同一个 *exec.Cmd
+// 并发调用两次 Wait 会在 cmd.ProcessState 等字段上产生 data race，
+// 而且只有一边能拿到真实的退出状态，另一边会直接报错。
+func (b *builder) stopGracefully(timeout time.Duration) {
+	b.cmdMux.Lock()
+	cmd, exited := b.cmd, b.exited
+	b.cmdMux.Unlock()
+
+	if cmd == nil || cmd.Process == nil {
+		return
+	}
+
+	if timeout <= 0 {
+		timeout = defaultGracefulTimeout
+	}
+
+	cmd.Process.Signal(syscall.SIGTERM)
+
+	select {
+	case <-exited:
+	case <-time.After(timeout):
+		cmd.Process.Kill()
+		<-exited
+	}
+
+	b.logs <- &Log{
+		Type:    LogTypeKilled,
+		Message: "已终止旧的进程",
+	}
+
+	b.runPostStop()
+}
+
+// waitReady 在子进程启动后，根据 probe 判断其是否已经就绪，
+// probe 为 nil 或 probe.Addr 为空时立即输出 Ready 日志。
+// 探测过程最多持续 overall（<=0 时不限制），任一次探测成功即返回。
+func (b *builder) waitReady(probe *ReadinessProbe, overall time.Duration) {
+	if probe == nil || probe.Addr == "" {
+		b.logs <- &Log{Type: LogTypeReady, Message: "程序已启动"}
+		return
+	}
+
+	timeout := probe.Timeout
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	interval := probe.Interval
+	if interval <= 0 {
+		interval = 100 * time.Millisecond
+	}
+
+	deadline := time.Time{}
+	if overall > 0 {
+		deadline = time.Now().Add(overall)
+	}
+
+	for {
+		if probeOnce(probe, timeout) {
+			b.logs <- &Log{Type: LogTypeReady, Message: fmt.Sprint("程序已就绪：", probe.Addr)}
+			return
+		}
+
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			b.logs <- &Log{
+				Type:    LogTypeWarn,
+				Message: fmt.Sprint("等待就绪探测超时：", probe.Addr),
+			}
+			return
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func probeOnce(probe *ReadinessProbe, timeout time.Duration) bool {
+	if probe.HTTP != "" {
+		client := http.Client{Timeout: timeout}
+		resp, err := client.Get("http://" + probe.Addr + probe.HTTP)
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode >= 200 && resp.StatusCode < 300
+	}
+
+	conn, err := net.DialTimeout("tcp", probe.Addr, timeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// reportExit 在子进程每一次退出时调用（包括被 stopGracefully 终止
+// 的情况），把退出码通过 LogTypeExited 上报。
+func (b *builder) reportExit(code int) {
+	b.logs <- &Log{
+		Type:    LogTypeExited,
+		Message: fmt.Sprintf("进程已退出，退出码：%d", code),
+	}
+}
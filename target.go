@@ -0,0 +1,234 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package gobuild
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Target 表示一个交叉编译的目标平台，对应 go build 中的
+// GOOS 和 GOARCH 环境变量。
+type Target struct {
+	GOOS   string
+	GOARCH string
+}
+
+// String 返回 GOOS_GOARCH 形式的字符串，用于日志标记以及
+// 输出目录的默认命名。
+func (t Target) String() string {
+	return t.GOOS + "_" + t.GOARCH
+}
+
+// exeSuffix 返回该平台下可执行文件的后缀名，Windows 平台固定
+// 返回 .exe，不依赖宿主机的 GOEXE 环境变量。
+func (t Target) exeSuffix() string {
+	if t.GOOS == "windows" {
+		return ".exe"
+	}
+	return ""
+}
+
+// isHost 判断该 Target 是否与当前宿主机的 GOOS/GOARCH 一致，
+// 只有宿主平台对应的二进制才会在构建完成后被 run 起来。
+func (t Target) isHost() bool {
+	return t.GOOS == runtime.GOOS && t.GOARCH == runtime.GOARCH
+}
+
+// BuildCross 与 Build 功能基本相同，区别在于它允许指定多个
+// GOOS/GOARCH 组合，每次触发编译时都会为 targets 中的每一个
+// 平台并行生成一个可执行文件，输出至 outputDir 下以
+// {{.GOOS}}_{{.GOARCH}} 命名的子目录中。
+//
+// outputDir 为空时使用当前工作目录下的 dist 目录；targets 至少
+// 指定一个元素。只有与宿主机 GOOS/GOARCH 相同的目标，在编译完成
+// 后才会执行 run 步骤，其余目标仅做编译。
+//
+// excludes 为 gitignore 风格的排除规则，含义与 BuildWithExcludes
+// 中的同名参数一致，可以为空。
+func BuildCross(logs chan *Log,
+	targets []Target,
+	outputDir string,
+	mainFiles string,
+	flags map[string]string,
+	exts string,
+	recursive bool,
+	excludes []string,
+	appArgs string,
+	dir ...string) error {
+	if len(targets) == 0 {
+		return fmt.Errorf("参数 targets 至少指定一个")
+	}
+	if len(dir) < 1 {
+		return fmt.Errorf("参数 dir 至少指定一个")
+	}
+
+	wd, err := filepath.Abs(dir[0])
+	if err != nil {
+		return err
+	}
+	dir[0] = wd
+
+	if outputDir == "" {
+		outputDir = filepath.Join(wd, "dist")
+	}
+	outputDir, err = filepath.Abs(outputDir)
+	if err != nil {
+		return err
+	}
+
+	appBase := filepath.Base(wd)
+
+	paths, m, err := recursivePathsWithExcludes(recursive, dir, excludes)
+	if err != nil {
+		return err
+	}
+
+	b := &builder{
+		exts:      getExts(exts),
+		wd:        wd,
+		appArgs:   splitArgs(appArgs),
+		verb:      "build",
+		mainFiles: mainFiles,
+		rawFlags:  flags,
+		logs:      logs,
+		targets:   targets,
+		outputDir: outputDir,
+		appBase:   appBase,
+		excludes:  m,
+	}
+
+	logs <- &Log{
+		Type:    LogTypeInfo,
+		Message: fmt.Sprint("交叉编译目标：", targetsString(targets)),
+	}
+
+	w, err := b.initWatcher(paths)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	b.watch(w)
+	b.forwardSignals()
+	go b.rebuild()
+
+	<-make(chan bool)
+	return nil
+}
+
+// buildTargets 为 b.targets 中的每一个平台并行执行一次编译，每个
+// 目标的日志都会带上该目标的标记，方便在混合输出中区分。编译全部
+// 完成之后，只有宿主平台对应的二进制、且该次编译确实成功时，才会
+// 被 run 起来；宿主平台编译失败时会跳过本次重启，而不是沿用上一次
+// 的 appName 重新执行一个从未产出或已经过时的二进制。rebuild() 会
+// 在每次 debounce 确认内容变化后重新调用本方法，因此并行 fan-out
+// 会随每次文件改变重复执行，而不仅仅发生在启动时。
+func (b *builder) buildTargets() {
+	if b.hooks != nil {
+		if err := runHooks(b.logs, b.wd, "pre-build", b.hooks.PreBuild); err != nil {
+			return
+		}
+	}
+
+	var wg sync.WaitGroup
+	var mux sync.Mutex
+	ok := make(map[Target]bool, len(b.targets))
+
+	var hostTarget Target
+	var hostOutput string
+	hasHost := false
+
+	for _, t := range b.targets {
+		t := t
+		output := filepath.Join(b.outputDir, t.String(), b.appBase+t.exeSuffix())
+		if t.isHost() {
+			hostTarget = t
+			hostOutput = output
+			hasHost = true
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			success := b.buildTarget(t, output)
+			mux.Lock()
+			ok[t] = success
+			mux.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	if !hasHost {
+		return
+	}
+
+	if !ok[hostTarget] {
+		b.logs <- &Log{
+			Type:    LogTypeWarn,
+			Message: fmt.Sprint("宿主平台编译失败，跳过本次重启：", hostTarget),
+		}
+		return
+	}
+
+	if b.hooks != nil {
+		if err := runHooks(b.logs, b.wd, "post-build", b.hooks.PostBuild); err != nil {
+			return
+		}
+		if err := runHooks(b.logs, b.wd, "pre-run", b.hooks.PreRun); err != nil {
+			return
+		}
+	}
+
+	b.appName = hostOutput
+	b.startApp()
+}
+
+// buildTarget 针对单个 target 执行一次 go build，env 中的 GOOS/
+// GOARCH 会覆盖当前进程继承的环境变量，不影响其它 target 的编译，
+// 参数由 b.args 组装（见 builder.args）。返回值表示该 target 是否
+// 编译成功，调用方应据此决定是否重启。
+func (b *builder) buildTarget(t Target, output string) bool {
+	args := b.args(output)
+
+	cmd := exec.Command("go", args...)
+	cmd.Dir = b.wd
+	cmd.Env = append(os.Environ(), "GOOS="+t.GOOS, "GOARCH="+t.GOARCH)
+
+	out, err := cmd.CombinedOutput()
+	if len(out) > 0 {
+		b.logs <- &Log{
+			Type:    LogTypeInfo,
+			Message: fmt.Sprintf("[%s] %s", t, strings.TrimSpace(string(out))),
+		}
+	}
+	if err != nil {
+		b.logs <- &Log{
+			Type:    LogTypeError,
+			Message: fmt.Sprintf("[%s] 编译失败：%s", t, err),
+		}
+		return false
+	}
+
+	b.logs <- &Log{
+		Type:    LogTypeSuccess,
+		Message: fmt.Sprintf("[%s] 编译成功，输出至 %s", t, output),
+	}
+	return true
+}
+
+func targetsString(targets []Target) string {
+	s := make([]string, 0, len(targets))
+	for _, t := range targets {
+		s = append(s, t.String())
+	}
+	return strings.Join(s, ", ")
+}
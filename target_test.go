@@ -0,0 +1,47 @@
+// Copyright 2015 by caixw, All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package gobuild
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestTarget_ExeSuffix(t *testing.T) {
+	cases := []struct {
+		target Target
+		want   string
+	}{
+		{Target{GOOS: "windows", GOARCH: "amd64"}, ".exe"},
+		{Target{GOOS: "windows", GOARCH: "386"}, ".exe"},
+		{Target{GOOS: "linux", GOARCH: "amd64"}, ""},
+		{Target{GOOS: "darwin", GOARCH: "arm64"}, ""},
+	}
+
+	for _, c := range cases {
+		if got := c.target.exeSuffix(); got != c.want {
+			t.Errorf("%v.exeSuffix() = %q, want %q", c.target, got, c.want)
+		}
+	}
+}
+
+func TestTarget_IsHost(t *testing.T) {
+	host := Target{GOOS: runtime.GOOS, GOARCH: runtime.GOARCH}
+	if !host.isHost() {
+		t.Fatalf("%v 应该与宿主机一致", host)
+	}
+
+	other := Target{GOOS: runtime.GOOS + "-not-real", GOARCH: runtime.GOARCH}
+	if other.isHost() {
+		t.Fatalf("%v 不应该与宿主机一致", other)
+	}
+}
+
+func TestTarget_String(t *testing.T) {
+	target := Target{GOOS: "linux", GOARCH: "amd64"}
+	if got, want := target.String(), "linux_amd64"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}